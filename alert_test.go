@@ -0,0 +1,86 @@
+// alert_test.go
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeHistoryStore - тестовая реализация Store, отдающая фиксированную
+// историю цены из PriceHistory; остальные методы не используются shouldAlertPriceDrop.
+type fakeHistoryStore struct {
+	history []PricePoint
+}
+
+func (s *fakeHistoryStore) UpsertTracking(chatID int64, article string, item TrackedItem) error {
+	return nil
+}
+func (s *fakeHistoryStore) DeleteTracking(chatID int64, article string) error { return nil }
+func (s *fakeHistoryStore) ListTracking() (map[int64]map[string]TrackedItem, error) {
+	return nil, nil
+}
+func (s *fakeHistoryStore) RecordPricePoint(chatID int64, article, size string, price float64, inStock bool, ts time.Time) error {
+	return nil
+}
+func (s *fakeHistoryStore) PriceHistory(chatID int64, article, size string, since time.Time) ([]PricePoint, error) {
+	return s.history, nil
+}
+
+func withFakeStore(history []PricePoint, fn func()) {
+	prev := store
+	store = &fakeHistoryStore{history: history}
+	defer func() { store = prev }()
+	fn()
+}
+
+func TestShouldAlertPriceDropTarget(t *testing.T) {
+	item := TrackedItem{TargetPrice: map[string]float64{"42": 80}}
+	withFakeStore(nil, func() {
+		alert, reason := shouldAlertPriceDrop(1, "wb:123", "42", item, 75, time.Now())
+		if !alert || reason != "target" {
+			t.Errorf("shouldAlertPriceDrop = (%v, %q), ожидалось (true, \"target\")", alert, reason)
+		}
+	})
+}
+
+func TestShouldAlertPriceDropPercentIgnoresOutOfStockHistory(t *testing.T) {
+	now := time.Now()
+	history := []PricePoint{
+		{Price: 200, InStock: false, Time: now.Add(-time.Hour)}, // выброс из-за обнуления при распродаже - не максимум
+		{Price: 100, InStock: true, Time: now.Add(-2 * time.Hour)},
+	}
+	item := TrackedItem{AlertPercent: 10}
+	withFakeStore(history, func() {
+		// 91 - это падение на 9% от истинного максимума 100, порог не должен сработать.
+		if alert, _ := shouldAlertPriceDrop(1, "wb:123", "42", item, 91, now); alert {
+			t.Errorf("shouldAlertPriceDrop(91) сработал, хотя падение меньше порога в 10%%")
+		}
+		// 89 - падение больше 10% от 100.
+		alert, reason := shouldAlertPriceDrop(1, "wb:123", "42", item, 89, now)
+		if !alert || reason != "percent" {
+			t.Errorf("shouldAlertPriceDrop(89) = (%v, %q), ожидалось (true, \"percent\")", alert, reason)
+		}
+	})
+}
+
+func TestShouldAlertPriceDropRespectsCooldown(t *testing.T) {
+	now := time.Now()
+	item := TrackedItem{
+		TargetPrice:        map[string]float64{"42": 80},
+		AlertCooldownUntil: map[string]time.Time{"42": now.Add(time.Hour)},
+	}
+	withFakeStore(nil, func() {
+		if alert, _ := shouldAlertPriceDrop(1, "wb:123", "42", item, 50, now); alert {
+			t.Errorf("shouldAlertPriceDrop сработал несмотря на активный cooldown")
+		}
+	})
+}
+
+func TestShouldAlertPriceDropNoThresholdsConfigured(t *testing.T) {
+	item := TrackedItem{}
+	withFakeStore(nil, func() {
+		if alert, _ := shouldAlertPriceDrop(1, "wb:123", "42", item, 1, time.Now()); alert {
+			t.Errorf("shouldAlertPriceDrop сработал без настроенных TargetPrice/AlertPercent")
+		}
+	})
+}
@@ -0,0 +1,301 @@
+// checker.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/time/rate"
+
+	"wb-price-bot/marketplace"
+)
+
+const (
+	priceCheckInterval = 10 * time.Minute
+	priceCheckJitter   = 2 * time.Minute
+	priceCheckWorkers  = 4
+	wbRequestsPerSec   = 1
+	initialBackoff     = 2 * time.Second
+	maxBackoff         = 2 * time.Minute
+
+	// defaultAlertLookbackWindow используется, если ALERT_LOOKBACK_WINDOW не задан.
+	defaultAlertLookbackWindow = 30 * 24 * time.Hour
+	// alertCooldown - как долго после срабатывания порога (целевая цена или
+	// процент падения) для одного размера подавляются повторные уведомления.
+	alertCooldown = 24 * time.Hour
+)
+
+// alertLookbackWindow - окно, за которое ищется исторический максимум цены
+// для алертов по проценту падения (см. shouldAlertPriceDrop). Задаётся через
+// ALERT_LOOKBACK_WINDOW (формат как у /history, например "30d"), см. initAlertLookbackWindow.
+var alertLookbackWindow = defaultAlertLookbackWindow
+
+// initAlertLookbackWindow читает ALERT_LOOKBACK_WINDOW из окружения и, если он
+// задан и корректен, переопределяет alertLookbackWindow. Вызывается из main().
+func initAlertLookbackWindow(raw string) {
+	if raw == "" {
+		return
+	}
+	d, err := parseWindowDuration(raw)
+	if err != nil {
+		log.Printf("ОШИБКА: некорректный ALERT_LOOKBACK_WINDOW=%q, использую значение по умолчанию: %v", raw, err)
+		return
+	}
+	alertLookbackWindow = d
+}
+
+// checkJob - одна задача на проверку цены товара у конкретного пользователя.
+// key - composite "маркетплейс:артикул" (см. trackingKey).
+type checkJob struct {
+	chatID int64
+	key    string
+	item   TrackedItem
+}
+
+// startPriceChecker запускает пул воркеров, которые с интервалом ~10 минут
+// (плюс-минус джиттер, чтобы не бить API маркетплейсов одной волной) опрашивают
+// все отслеживаемые товары. Запросы ограничены общим токен-бакетом, а ctx
+// позволяет корректно остановить в работающие запросы при завершении.
+func startPriceChecker(ctx context.Context, bot *tgbotapi.BotAPI) {
+	limiter := rate.NewLimiter(rate.Limit(wbRequestsPerSec), 1)
+	jobs := make(chan checkJob)
+
+	var wg sync.WaitGroup
+	for i := 0; i < priceCheckWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkWorker(ctx, limiter, jobs)
+		}()
+	}
+
+	for {
+		jitter := time.Duration(rand.Int63n(int64(priceCheckJitter))) - priceCheckJitter/2
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return
+		case <-time.After(priceCheckInterval + jitter):
+		}
+		log.Println("Запущена периодическая проверка цен...")
+		dispatchChecks(ctx, jobs)
+		lastSuccessfulCheckTimestamp.Set(float64(time.Now().Unix()))
+	}
+}
+
+// dispatchChecks кладёт в канал jobs по одной задаче на каждый отслеживаемый товар.
+// Каждому job достаётся clone() товара, а не сам TrackedItem из trackingData:
+// иначе карты внутри него (TargetPrice, AlertCooldownUntil, ...) остались бы
+// общими с живой записью, и воркер читал бы их без mu.RLock, пока обработчики
+// команд пишут в них под mu.Lock - гарантированный "concurrent map read and
+// map write".
+func dispatchChecks(ctx context.Context, jobs chan<- checkJob) {
+	mu.RLock()
+	snapshot := make([]checkJob, 0)
+	for chatID, items := range trackingData {
+		for key, item := range items {
+			snapshot = append(snapshot, checkJob{chatID: chatID, key: key, item: item.clone()})
+		}
+	}
+	mu.RUnlock()
+	trackedItemsTotal.Set(float64(len(snapshot)))
+
+	for _, job := range snapshot {
+		select {
+		case <-ctx.Done():
+			return
+		case jobs <- job:
+		}
+	}
+}
+
+func checkWorker(ctx context.Context, limiter *rate.Limiter, jobs <-chan checkJob) {
+	for job := range jobs {
+		processCheckJob(ctx, limiter, job)
+	}
+}
+
+// fetchWithBackoff запрашивает карточку товара у маркетплейса, соблюдая общий
+// лимит запросов и повторяя попытку с экспоненциальным backoff при временных
+// ошибках (429/5xx).
+func fetchWithBackoff(ctx context.Context, limiter *rate.Limiter, mp marketplace.Marketplace, article string) (*marketplace.ProductInfo, error) {
+	backoff := initialBackoff
+	for {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		product, err := mp.Fetch(article)
+		marketplaceRequestDuration.WithLabelValues(mp.Name()).Observe(time.Since(start).Seconds())
+		marketplaceRequestsTotal.WithLabelValues(mp.Name(), requestStatusLabel(err)).Inc()
+		if err == nil {
+			return product, nil
+		}
+		if !isRetryableError(err) {
+			return nil, err
+		}
+		log.Printf("Временная ошибка API %s для артикула %s, повтор через %s: %v", mp.Name(), article, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// isRetryableError считает повторяемыми ответы 429 и 5xx от любого маркетплейса.
+func isRetryableError(err error) bool {
+	var retryErr *marketplace.RetryableError
+	if !errors.As(err, &retryErr) {
+		return false
+	}
+	return retryErr.StatusCode == 429 || retryErr.StatusCode >= 500
+}
+
+// shouldAlertPriceDrop решает, нужно ли уведомлять о текущей цене размера:
+// срабатывает либо достижение TargetPrice, либо падение на AlertPercent от
+// исторического максимума за alertLookbackWindow. AlertCooldownUntil не даёт
+// слать одно и то же уведомление на каждом опросе, пока цена остаётся низкой.
+func shouldAlertPriceDrop(chatID int64, key, sizeName string, item TrackedItem, newPrice float64, now time.Time) (bool, string) {
+	if cooldownUntil, ok := item.AlertCooldownUntil[sizeName]; ok && now.Before(cooldownUntil) {
+		return false, ""
+	}
+	if target, ok := item.TargetPrice[sizeName]; ok && newPrice <= target {
+		return true, "target"
+	}
+	if item.AlertPercent <= 0 {
+		return false, ""
+	}
+	points, err := store.PriceHistory(chatID, key, sizeName, now.Add(-alertLookbackWindow))
+	if err != nil {
+		log.Printf("ОШИБКА: не удалось получить историю цены для проверки порога %s/%s: %v", key, sizeName, err)
+		return false, ""
+	}
+	referencePrice := newPrice
+	for _, p := range points {
+		if p.InStock && p.Price > referencePrice {
+			referencePrice = p.Price
+		}
+	}
+	if newPrice <= referencePrice*(1-item.AlertPercent/100) {
+		return true, "percent"
+	}
+	return false, ""
+}
+
+// markAlertCooldown подавляет повторные алерты по размеру на alertCooldown.
+func markAlertCooldown(chatID int64, key, sizeName string, now time.Time) {
+	mu.Lock()
+	item := trackingData[chatID][key]
+	if item.AlertCooldownUntil == nil {
+		item.AlertCooldownUntil = make(map[string]time.Time)
+	}
+	item.AlertCooldownUntil[sizeName] = now.Add(alertCooldown)
+	trackingData[chatID][key] = item
+	mu.Unlock()
+}
+
+// processCheckJob опрашивает один товар, сравнивает цены по размерам
+// с последним известным состоянием и шлёт уведомления о смене наличия и о
+// срабатывании порогов цены (см. shouldAlertPriceDrop). Изменение цены без
+// срабатывания порога только сохраняется, уведомления о нём нет.
+func processCheckJob(ctx context.Context, limiter *rate.Limiter, job checkJob) {
+	chatID, key, oldItem := job.chatID, job.key, job.item
+	mpName, article := parseTrackingKey(key)
+	mp, ok := marketplaces[mpName]
+	if !ok {
+		log.Printf("ОШИБКА: неизвестный маркетплейс %s для товара %s", mpName, key)
+		return
+	}
+
+	newProductInfo, err := fetchWithBackoff(ctx, limiter, mp, article)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Ошибка проверки товара %s: %v", key, err)
+		}
+		return
+	}
+
+	newSizesMap := make(map[string]marketplace.SizeInfo)
+	for _, s := range newProductInfo.Sizes {
+		newSizesMap[s.Name] = s
+	}
+
+	now := time.Now()
+	muted := now.Before(oldItem.MutedUntil)
+	var anyChangeHappened bool
+	for sizeName, oldPrice := range oldItem.LastPrices {
+		var message string
+		var priceChanged bool
+		newSize, isNowInStock := newSizesMap[sizeName]
+		isNowInStock = isNowInStock && newSize.InStock
+		wasInStock := oldPrice > 0.0
+
+		var polledPrice float64
+		if isNowInStock {
+			polledPrice = newSize.Price
+		}
+		if err := store.RecordPricePoint(chatID, key, sizeName, polledPrice, isNowInStock, now); err != nil {
+			log.Printf("ОШИБКА: не удалось записать историю цены для %s/%s: %v", key, sizeName, err)
+		}
+
+		if wasInStock && !isNowInStock {
+			message = fmt.Sprintf("Товар *закончился* 😱\n\nТовар: *%s*\nID: `%s`\nРазмер: *%s*", oldItem.ProductName, key, sizeName)
+			mu.Lock()
+			trackingData[chatID][key].LastPrices[sizeName] = 0.0
+			mu.Unlock()
+			priceChanged = true
+		} else if !wasInStock && isNowInStock {
+			message = fmt.Sprintf("*Снова в наличии!* ✅\n\nТовар: *%s*\nID: `%s`\nРазмер: *%s*\n\nНовая цена: `%.2f BYN`", oldItem.ProductName, key, sizeName, newSize.Price)
+			mu.Lock()
+			trackingData[chatID][key].LastPrices[sizeName] = newSize.Price
+			mu.Unlock()
+			priceChanged = true
+		} else if wasInStock && isNowInStock {
+			newPrice := newSize.Price
+			if newPrice != oldPrice {
+				mu.Lock()
+				trackingData[chatID][key].LastPrices[sizeName] = newPrice
+				mu.Unlock()
+				priceChanged = true
+			}
+			if alert, reason := shouldAlertPriceDrop(chatID, key, sizeName, oldItem, newPrice, now); alert {
+				switch reason {
+				case "target":
+					message = fmt.Sprintf("🎯 *Достигнута целевая цена!*\n\nТовар: *%s*\nID: `%s`\nРазмер: *%s*\n\nТекущая цена: `%.2f BYN`\nЦель: `%.2f BYN`", oldItem.ProductName, key, sizeName, newPrice, oldItem.TargetPrice[sizeName])
+				case "percent":
+					message = fmt.Sprintf("📉 *Цена упала на %.0f%% и более от максимума!*\n\nТовар: *%s*\nID: `%s`\nРазмер: *%s*\n\nТекущая цена: `%.2f BYN`", oldItem.AlertPercent, oldItem.ProductName, key, sizeName, newPrice)
+				}
+				markAlertCooldown(chatID, key, sizeName, now)
+				priceChanged = true
+			}
+		}
+		trackThisSize := len(oldItem.RequestedSizes) == 0 || oldItem.RequestedSizes[sizeName]
+		if message != "" && trackThisSize && !muted {
+			log.Println("Найдено изменение:", message)
+			notify(chatID, oldItem.Channels, PriceEvent{Kind: "price_change", Article: key, Size: sizeName, Message: message})
+		}
+		if priceChanged {
+			anyChangeHappened = true
+		}
+	}
+	if anyChangeHappened {
+		mu.RLock()
+		updatedItem := trackingData[chatID][key]
+		mu.RUnlock()
+		if err := store.UpsertTracking(chatID, key, updatedItem); err != nil {
+			log.Printf("ОШИБКА: не удалось сохранить обновленную цену: %v", err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// checker_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"wb-price-bot/marketplace"
+)
+
+// fakeMarketplace - тестовая реализация marketplace.Marketplace с управляемым Fetch.
+type fakeMarketplace struct {
+	fetch func(article string) (*marketplace.ProductInfo, error)
+}
+
+func (m *fakeMarketplace) Name() string { return "fake" }
+
+func (m *fakeMarketplace) Fetch(article string) (*marketplace.ProductInfo, error) {
+	return m.fetch(article)
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"429", &marketplace.RetryableError{StatusCode: 429}, true},
+		{"500", &marketplace.RetryableError{StatusCode: 500}, true},
+		{"503", &marketplace.RetryableError{StatusCode: 503}, true},
+		{"400", &marketplace.RetryableError{StatusCode: 400}, false},
+		{"обычная ошибка", errors.New("boom"), false},
+	}
+	for _, c := range cases {
+		if got := isRetryableError(c.err); got != c.want {
+			t.Errorf("isRetryableError(%s) = %v, ожидалось %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFetchWithBackoffNonRetryableFailsImmediately(t *testing.T) {
+	calls := 0
+	mp := &fakeMarketplace{fetch: func(article string) (*marketplace.ProductInfo, error) {
+		calls++
+		return nil, errors.New("не наш код ошибки")
+	}}
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	_, err := fetchWithBackoff(context.Background(), limiter, mp, "123")
+	if err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	if calls != 1 {
+		t.Errorf("Fetch вызван %d раз, ожидался 1 (нет повтора для неповторяемой ошибки)", calls)
+	}
+}
+
+func TestFetchWithBackoffRetriesThenSucceeds(t *testing.T) {
+	calls := 0
+	want := &marketplace.ProductInfo{ID: "123", Name: "Товар"}
+	mp := &fakeMarketplace{fetch: func(article string) (*marketplace.ProductInfo, error) {
+		calls++
+		if calls == 1 {
+			return nil, &marketplace.RetryableError{StatusCode: 503}
+		}
+		return want, nil
+	}}
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	got, err := fetchWithBackoff(context.Background(), limiter, mp, "123")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got != want {
+		t.Errorf("fetchWithBackoff вернул %+v, ожидался %+v", got, want)
+	}
+	if calls != 2 {
+		t.Errorf("Fetch вызван %d раз, ожидалось 2 (один повтор после 503)", calls)
+	}
+}
+
+func TestFetchWithBackoffStopsOnContextCancel(t *testing.T) {
+	mp := &fakeMarketplace{fetch: func(article string) (*marketplace.ProductInfo, error) {
+		return nil, &marketplace.RetryableError{StatusCode: 503}
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	go func() {
+		// Отменяем контекст во время ожидания backoff, не дожидаясь initialBackoff целиком.
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := fetchWithBackoff(ctx, limiter, mp, "123")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("fetchWithBackoff вернул %v, ожидался context.Canceled", err)
+	}
+}
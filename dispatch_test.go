@@ -0,0 +1,60 @@
+// dispatch_test.go
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestDispatchChecksClonesMapsUnderConcurrentMutation воспроизводит гонку,
+// из-за которой /target или /percent, выполненные параллельно с фоновой
+// проверкой цен для того же товара, приводили к фатальному "concurrent map
+// read and map write": dispatchChecks раньше копировал TrackedItem по
+// значению, но TargetPrice/AlertCooldownUntil - карты, так что снимок
+// job.item аliasил ту же карту, что и живая запись в trackingData. Запускать
+// с -race: без clone() в dispatchChecks (см. main.go) этот тест падает.
+func TestDispatchChecksClonesMapsUnderConcurrentMutation(t *testing.T) {
+	mu.Lock()
+	trackingData = map[int64]map[string]TrackedItem{
+		1: {"wb:123": {
+			LastPrices:         map[string]float64{"42": 50},
+			TargetPrice:        map[string]float64{"42": 100},
+			AlertCooldownUntil: map[string]time.Time{},
+		}},
+	}
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		trackingData = make(map[int64]map[string]TrackedItem)
+		mu.Unlock()
+	}()
+
+	withFakeStore(nil, func() {
+		ctx := context.Background()
+		jobs := make(chan checkJob, 1)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				// Эмулирует handleTargetPriceRequest/markAlertCooldown: мутация
+				// карт живого TrackedItem под mu.Lock из другой горутины.
+				mu.Lock()
+				item := trackingData[1]["wb:123"]
+				item.TargetPrice["42"] = float64(i)
+				item.AlertCooldownUntil["42"] = time.Now()
+				trackingData[1]["wb:123"] = item
+				mu.Unlock()
+			}
+		}()
+
+		for i := 0; i < 200; i++ {
+			dispatchChecks(ctx, jobs)
+			job := <-jobs
+			// Эмулирует processCheckJob: чтение снимка job.item без mu.RLock.
+			shouldAlertPriceDrop(job.chatID, job.key, "42", job.item, 40, time.Now())
+		}
+		<-done
+	})
+}
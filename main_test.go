@@ -0,0 +1,54 @@
+// main_test.go
+package main
+
+import (
+	"testing"
+
+	"wb-price-bot/marketplace"
+)
+
+// fakeMarketplaceClient - пустая реализация marketplace.Marketplace, нужная
+// только чтобы зарегистрировать "wb"/"ozon" в marketplaces для тестов
+// parseTrackCommand (который явно прописанный маркетплейс ищет именно там).
+type fakeMarketplaceClient struct{ name string }
+
+func (c fakeMarketplaceClient) Name() string { return c.name }
+func (c fakeMarketplaceClient) Fetch(article string) (*marketplace.ProductInfo, error) {
+	return nil, nil
+}
+
+func init() {
+	marketplaces["wb"] = fakeMarketplaceClient{name: "wb"}
+	marketplaces["ozon"] = fakeMarketplaceClient{name: "ozon"}
+}
+
+func TestParseTrackCommandRejectsNonNumericArticle(t *testing.T) {
+	cases := [][]string{
+		{"abc"},
+		{"wb", "abc"},
+		{"ozon", "abc", "42"},
+	}
+	for _, args := range cases {
+		if _, _, _, err := parseTrackCommand(args); err == nil {
+			t.Errorf("parseTrackCommand(%v): ожидалась ошибка для нечислового артикула", args)
+		}
+	}
+}
+
+func TestParseTrackCommandAcceptsNumericArticle(t *testing.T) {
+	mpName, article, rest, err := parseTrackCommand([]string{"123456", "38", "39"})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if mpName != "wb" || article != "123456" || len(rest) != 2 {
+		t.Errorf("parseTrackCommand вернул (%q, %q, %v), ожидалось (\"wb\", \"123456\", [38 39])", mpName, article, rest)
+	}
+
+	mpName, article, rest, err = parseTrackCommand([]string{"ozon", "654321"})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if mpName != "ozon" || article != "654321" || len(rest) != 0 {
+		t.Errorf("parseTrackCommand вернул (%q, %q, %v), ожидалось (\"ozon\", \"654321\", [])", mpName, article, rest)
+	}
+}
@@ -0,0 +1,40 @@
+// Package marketplace описывает абстракцию над конкретными магазинами
+// (Wildberries, Ozon, ...), чтобы остальной бот работал с единым
+// ProductInfo и не знал о формате ответа конкретного API.
+package marketplace
+
+import "fmt"
+
+// SizeInfo - цена и наличие одного размера товара в нормализованном виде.
+type SizeInfo struct {
+	Name    string
+	Price   float64
+	InStock bool
+}
+
+// ProductInfo - нормализованные данные о товаре независимо от маркетплейса.
+type ProductInfo struct {
+	ID    string
+	Name  string
+	Sizes []SizeInfo
+}
+
+// Marketplace - клиент конкретного магазина, умеющий получить карточку товара по артикулу.
+type Marketplace interface {
+	// Name возвращает короткий ключ маркетплейса, например "wb" или "ozon",
+	// используемый в командах бота и как часть ключа trackingData.
+	Name() string
+	Fetch(article string) (*ProductInfo, error)
+}
+
+// RetryableError - ответ API маркетплейса с кодом, отличным от 200.
+// Реализации Marketplace возвращают её на любой такой ответ, сохраняя код;
+// checker.go повторяет с общим backoff только 429/5xx (см. isRetryableError).
+type RetryableError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *RetryableError) Error() string {
+	return fmt.Sprintf("маркетплейс вернул временную ошибку, код: %d, тело ответа: %s", e.StatusCode, e.Body)
+}
@@ -0,0 +1,87 @@
+// metrics.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"wb-price-bot/marketplace"
+)
+
+// defaultMetricsAddr используется, если METRICS_ADDR не задан.
+const defaultMetricsAddr = ":9090"
+
+var (
+	marketplaceRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wb_price_bot_marketplace_request_duration_seconds",
+		Help:    "Длительность запроса карточки товара к API маркетплейса.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"marketplace"})
+
+	marketplaceRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wb_price_bot_marketplace_requests_total",
+		Help: "Количество запросов карточки товара к маркетплейсу по итоговому статусу.",
+	}, []string{"marketplace", "status"})
+
+	trackedItemsTotal = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "wb_price_bot_tracked_items_total",
+		Help: "Текущее количество отслеживаемых пар (пользователь, товар).",
+	})
+
+	lastSuccessfulCheckTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "wb_price_bot_last_check_timestamp_seconds",
+		Help: "Unix-время последнего завершённого прохода периодической проверки цен.",
+	})
+
+	commandsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wb_price_bot_commands_total",
+		Help: "Количество обработанных команд Telegram по имени.",
+	}, []string{"command"})
+)
+
+// startMetricsServer поднимает HTTP-сервер с /metrics (для Prometheus) и
+// /healthz, /readyz - для проверок живости/готовности в оркестраторе, чтобы
+// не зависеть от bot.Debug и логов при развёртывании за реальным мониторингом.
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if store == nil || len(marketplaces) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ready"))
+	})
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("ОШИБКА: сервер метрик остановлен: %v", err)
+		}
+	}()
+	log.Printf("Метрики доступны на http://%s/metrics", addr)
+}
+
+// requestStatusLabel переводит ошибку запроса к маркетплейсу в короткий label
+// для marketplaceRequestsTotal: "ok", "error_<код>" для ответов API с кодом,
+// отличным от 200, или просто "error" для прочих (сеть, парсинг и т.п.).
+func requestStatusLabel(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var retryErr *marketplace.RetryableError
+	if errors.As(err, &retryErr) {
+		return fmt.Sprintf("error_%d", retryErr.StatusCode)
+	}
+	return "error"
+}
@@ -0,0 +1,196 @@
+// notifier.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gorilla/websocket"
+)
+
+// --- АБСТРАКЦИЯ КАНАЛОВ ДОСТАВКИ УВЕДОМЛЕНИЙ ---
+
+// PriceEvent описывает событие, о котором нужно сообщить пользователю
+// (снижение/повышение цены, товар закончился/снова в наличии, обычный ответ бота).
+type PriceEvent struct {
+	Kind    string `json:"kind"`
+	Article string `json:"article,omitempty"`
+	Size    string `json:"size,omitempty"`
+	Message string `json:"message"`
+}
+
+// Notifier - канал доставки уведомлений пользователю.
+type Notifier interface {
+	Name() string
+	Notify(userID int64, event PriceEvent) error
+}
+
+// defaultChannels используется для товаров, у которых ещё не выбраны каналы.
+var defaultChannels = []string{"telegram"}
+
+// notifiers хранит зарегистрированные каналы по имени, см. main().
+var notifiers = make(map[string]Notifier)
+
+// notify рассылает событие пользователю по всем указанным каналам.
+// Если channels пуст, используется defaultChannels.
+func notify(chatID int64, channels []string, event PriceEvent) {
+	if len(channels) == 0 {
+		channels = defaultChannels
+	}
+	for _, ch := range channels {
+		n, ok := notifiers[ch]
+		if !ok {
+			log.Printf("ОШИБКА: неизвестный канал уведомлений: %s", ch)
+			continue
+		}
+		if err := n.Notify(chatID, event); err != nil {
+			log.Printf("ОШИБКА: не удалось отправить уведомление через %s: %v", ch, err)
+		}
+	}
+}
+
+// --- TELEGRAM ---
+
+// TelegramNotifier отправляет уведомления через обычные сообщения бота.
+type TelegramNotifier struct {
+	bot *tgbotapi.BotAPI
+}
+
+func NewTelegramNotifier(bot *tgbotapi.BotAPI) *TelegramNotifier {
+	return &TelegramNotifier{bot: bot}
+}
+
+func (t *TelegramNotifier) Name() string { return "telegram" }
+
+func (t *TelegramNotifier) Notify(userID int64, event PriceEvent) error {
+	msg := tgbotapi.NewMessage(userID, event.Message)
+	msg.ParseMode = "Markdown"
+	if _, err := t.bot.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения в Telegram: %w", err)
+	}
+	return nil
+}
+
+// --- ВЕБ / WEBSOCKET ---
+
+// WebNotifier рассылает события подключённым веб-клиентам по WebSocket
+// и позволяет запускать бота в режиме веб-дашборда.
+type WebNotifier struct {
+	mu       sync.Mutex
+	conns    map[int64][]*websocket.Conn
+	upgrader websocket.Upgrader
+}
+
+func NewWebNotifier() *WebNotifier {
+	return &WebNotifier{
+		conns: make(map[int64][]*websocket.Conn),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+func (w *WebNotifier) Name() string { return "web" }
+
+// HandleWS апгрейдит HTTP-соединение до WebSocket для пользователя из ?user=.
+func (w *WebNotifier) HandleWS(rw http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.URL.Query().Get("user"), 10, 64)
+	if err != nil {
+		http.Error(rw, "некорректный параметр user", http.StatusBadRequest)
+		return
+	}
+	conn, err := w.upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		log.Printf("ОШИБКА: не удалось установить WebSocket-соединение: %v", err)
+		return
+	}
+	w.mu.Lock()
+	w.conns[userID] = append(w.conns[userID], conn)
+	w.mu.Unlock()
+}
+
+func (w *WebNotifier) Notify(userID int64, event PriceEvent) error {
+	w.mu.Lock()
+	conns := append([]*websocket.Conn(nil), w.conns[userID]...)
+	w.mu.Unlock()
+	if len(conns) == 0 {
+		return fmt.Errorf("нет активных веб-подключений для пользователя %d", userID)
+	}
+	var alive []*websocket.Conn
+	var lastErr error
+	for _, c := range conns {
+		if err := c.WriteJSON(event); err != nil {
+			lastErr = err
+			c.Close()
+			continue
+		}
+		alive = append(alive, c)
+	}
+	w.mu.Lock()
+	w.conns[userID] = alive
+	w.mu.Unlock()
+	return lastErr
+}
+
+// --- EMAIL ---
+
+// EmailNotifier отправляет уведомления по SMTP на адрес, привязанный к chatID.
+type EmailNotifier struct {
+	smtpAddr   string
+	auth       smtp.Auth
+	from       string
+	userEmails map[int64]string
+}
+
+func NewEmailNotifier(smtpAddr, username, password, from string, userEmails map[int64]string) *EmailNotifier {
+	host := smtpAddr
+	if idx := strings.Index(smtpAddr, ":"); idx != -1 {
+		host = smtpAddr[:idx]
+	}
+	return &EmailNotifier{
+		smtpAddr:   smtpAddr,
+		auth:       smtp.PlainAuth("", username, password, host),
+		from:       from,
+		userEmails: userEmails,
+	}
+}
+
+func (e *EmailNotifier) Name() string { return "email" }
+
+func (e *EmailNotifier) Notify(userID int64, event PriceEvent) error {
+	to, ok := e.userEmails[userID]
+	if !ok {
+		return fmt.Errorf("для пользователя %d не настроен email-адрес", userID)
+	}
+	body := fmt.Sprintf("Subject: wb-price-bot: %s\r\n\r\n%s", event.Kind, event.Message)
+	if err := smtp.SendMail(e.smtpAddr, e.auth, e.from, []string{to}, []byte(body)); err != nil {
+		return fmt.Errorf("ошибка отправки email-уведомления: %w", err)
+	}
+	return nil
+}
+
+// parseUserEmails разбирает EMAIL_RECIPIENTS вида "123:a@b.com,456:c@d.com".
+func parseUserEmails(raw string) map[int64]string {
+	result := make(map[int64]string)
+	if raw == "" {
+		return result
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		chatID, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			continue
+		}
+		result[chatID] = strings.TrimSpace(parts[1])
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+// Package ozon реализует marketplace.Marketplace поверх публичного API карточки товара Ozon.
+package ozon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"wb-price-bot/marketplace"
+)
+
+// --- Структуры для парсинга ответа composer-api Ozon ---
+
+type widgetState struct {
+	Price struct {
+		CardPrice string `json:"cardPrice"`
+	} `json:"webPrice"`
+	Name string `json:"name"`
+}
+
+type pageResponse struct {
+	Widgets map[string]json.RawMessage `json:"widgetStates"`
+}
+
+// Client - клиент публичного composer-api Ozon (api.ozon.ru).
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient создаёт клиент Ozon с таймаутом на запрос к API.
+func NewClient() *Client {
+	return &Client{httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *Client) Name() string { return "ozon" }
+
+// Fetch запрашивает карточку товара по артикулу (SKU) Ozon. В отличие от WB,
+// у Ozon нет стабильного публичного ID размера - сайт отдаёт одну цену на
+// карточку, поэтому результат содержит один виртуальный размер "one size".
+func (c *Client) Fetch(article string) (*marketplace.ProductInfo, error) {
+	url := fmt.Sprintf("https://api.ozon.ru/composer-api.bx/page/json/v2?url=/product/%s", article)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании запроса: %w", err)
+	}
+	req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса к Ozon API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return nil, &marketplace.RetryableError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении тела ответа: %w", err)
+	}
+
+	var page pageResponse
+	if err := json.Unmarshal(bodyBytes, &page); err != nil {
+		return nil, fmt.Errorf("ошибка при декодировании JSON: %w. Ответ сервера был: %s", err, string(bodyBytes))
+	}
+
+	for key, raw := range page.Widgets {
+		if !isPriceWidget(key) {
+			continue
+		}
+		var w widgetState
+		if err := json.Unmarshal(raw, &w); err != nil {
+			continue
+		}
+		price, err := parsePrice(w.Price.CardPrice)
+		if err != nil {
+			continue
+		}
+		return &marketplace.ProductInfo{
+			ID:   article,
+			Name: w.Name,
+			Sizes: []marketplace.SizeInfo{
+				{Name: "one size", Price: price, InStock: price > 0},
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("товар с артикулом %s не найден", article)
+}
+
+// isPriceWidget определяет, что ключ widgetStates относится к блоку цены
+// (вида "webPrice-123456").
+func isPriceWidget(key string) bool {
+	return len(key) >= 8 && key[:8] == "webPrice"
+}
+
+// parsePrice разбирает цену вида "1 234 ₽" в число.
+func parsePrice(raw string) (float64, error) {
+	var digits []rune
+	for _, r := range raw {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) == 0 {
+		return 0, fmt.Errorf("не удалось разобрать цену: %q", raw)
+	}
+	var value float64
+	for _, d := range digits {
+		value = value*10 + float64(d-'0')
+	}
+	return value, nil
+}
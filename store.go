@@ -0,0 +1,313 @@
+// store.go
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// --- ХРАНИЛИЩЕ НА БАЗЕ SQLITE ---
+
+const sqliteFileName = "tracking.db"
+
+// PricePoint - одна точка истории цены товара, снятая при очередном опросе.
+type PricePoint struct {
+	Price   float64
+	InStock bool
+	Time    time.Time
+}
+
+// Store описывает хранилище отслеживаемых товаров и истории их цен.
+// Позволяет заменить бэкенд хранения (сейчас - SQLite) без изменения остального кода.
+type Store interface {
+	UpsertTracking(chatID int64, article string, item TrackedItem) error
+	DeleteTracking(chatID int64, article string) error
+	ListTracking() (map[int64]map[string]TrackedItem, error)
+	RecordPricePoint(chatID int64, article, size string, price float64, inStock bool, ts time.Time) error
+	PriceHistory(chatID int64, article, size string, since time.Time) ([]PricePoint, error)
+}
+
+// sqliteStore - реализация Store поверх modernc.org/sqlite (без cgo).
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии базы данных: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("ошибка при подключении к базе данных: %w", err)
+	}
+	s := &sqliteStore{db: db}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqliteStore) migrate() error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS tracked_items (
+		chat_id INTEGER NOT NULL,
+		article TEXT NOT NULL,
+		product_name TEXT NOT NULL,
+		requested_sizes TEXT NOT NULL,
+		last_prices TEXT NOT NULL,
+		channels TEXT NOT NULL DEFAULT '[]',
+		muted_until DATETIME,
+		target_price TEXT NOT NULL DEFAULT '{}',
+		alert_percent REAL NOT NULL DEFAULT 0,
+		alert_cooldown_until TEXT NOT NULL DEFAULT '{}',
+		PRIMARY KEY (chat_id, article)
+	);
+	CREATE TABLE IF NOT EXISTS price_history (
+		chat_id INTEGER NOT NULL,
+		article TEXT NOT NULL,
+		size TEXT NOT NULL,
+		price REAL NOT NULL,
+		in_stock INTEGER NOT NULL,
+		ts DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_price_history_lookup ON price_history (chat_id, article, size, ts);
+	`
+	if _, err := s.db.Exec(schema); err != nil {
+		return fmt.Errorf("ошибка при миграции схемы базы данных: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) UpsertTracking(chatID int64, article string, item TrackedItem) error {
+	sizesJSON, err := json.Marshal(item.RequestedSizes)
+	if err != nil {
+		return fmt.Errorf("ошибка при маршалинге requestedSizes: %w", err)
+	}
+	pricesJSON, err := json.Marshal(item.LastPrices)
+	if err != nil {
+		return fmt.Errorf("ошибка при маршалинге lastPrices: %w", err)
+	}
+	channelsJSON, err := json.Marshal(item.Channels)
+	if err != nil {
+		return fmt.Errorf("ошибка при маршалинге channels: %w", err)
+	}
+	targetPriceJSON, err := json.Marshal(item.TargetPrice)
+	if err != nil {
+		return fmt.Errorf("ошибка при маршалинге targetPrice: %w", err)
+	}
+	cooldownJSON, err := json.Marshal(item.AlertCooldownUntil)
+	if err != nil {
+		return fmt.Errorf("ошибка при маршалинге alertCooldownUntil: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO tracked_items (chat_id, article, product_name, requested_sizes, last_prices, channels, muted_until, target_price, alert_percent, alert_cooldown_until)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, article) DO UPDATE SET
+			product_name = excluded.product_name,
+			requested_sizes = excluded.requested_sizes,
+			last_prices = excluded.last_prices,
+			channels = excluded.channels,
+			muted_until = excluded.muted_until,
+			target_price = excluded.target_price,
+			alert_percent = excluded.alert_percent,
+			alert_cooldown_until = excluded.alert_cooldown_until
+	`, chatID, article, item.ProductName, string(sizesJSON), string(pricesJSON), string(channelsJSON), item.MutedUntil.UTC(),
+		string(targetPriceJSON), item.AlertPercent, string(cooldownJSON))
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении отслеживаемого товара: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) DeleteTracking(chatID int64, article string) error {
+	_, err := s.db.Exec(`DELETE FROM tracked_items WHERE chat_id = ? AND article = ?`, chatID, article)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении отслеживаемого товара: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) ListTracking() (map[int64]map[string]TrackedItem, error) {
+	rows, err := s.db.Query(`SELECT chat_id, article, product_name, requested_sizes, last_prices, channels, muted_until, target_price, alert_percent, alert_cooldown_until FROM tracked_items`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении отслеживаемых товаров: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]map[string]TrackedItem)
+	for rows.Next() {
+		var chatID int64
+		var article, productName, sizesJSON, pricesJSON, channelsJSON, targetPriceJSON, cooldownJSON string
+		var mutedUntil sql.NullTime
+		var alertPercent float64
+		if err := rows.Scan(&chatID, &article, &productName, &sizesJSON, &pricesJSON, &channelsJSON, &mutedUntil, &targetPriceJSON, &alertPercent, &cooldownJSON); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки отслеживаемого товара: %w", err)
+		}
+		item := TrackedItem{ProductName: productName, AlertPercent: alertPercent}
+		if mutedUntil.Valid {
+			item.MutedUntil = mutedUntil.Time
+		}
+		if err := json.Unmarshal([]byte(sizesJSON), &item.RequestedSizes); err != nil {
+			return nil, fmt.Errorf("ошибка при анмаршалинге requestedSizes: %w", err)
+		}
+		if err := json.Unmarshal([]byte(pricesJSON), &item.LastPrices); err != nil {
+			return nil, fmt.Errorf("ошибка при анмаршалинге lastPrices: %w", err)
+		}
+		if err := json.Unmarshal([]byte(channelsJSON), &item.Channels); err != nil {
+			return nil, fmt.Errorf("ошибка при анмаршалинге channels: %w", err)
+		}
+		if err := json.Unmarshal([]byte(targetPriceJSON), &item.TargetPrice); err != nil {
+			return nil, fmt.Errorf("ошибка при анмаршалинге targetPrice: %w", err)
+		}
+		if err := json.Unmarshal([]byte(cooldownJSON), &item.AlertCooldownUntil); err != nil {
+			return nil, fmt.Errorf("ошибка при анмаршалинге alertCooldownUntil: %w", err)
+		}
+		if _, ok := result[chatID]; !ok {
+			result[chatID] = make(map[string]TrackedItem)
+		}
+		result[chatID][article] = item
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при переборе отслеживаемых товаров: %w", err)
+	}
+	return result, nil
+}
+
+func (s *sqliteStore) RecordPricePoint(chatID int64, article, size string, price float64, inStock bool, ts time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO price_history (chat_id, article, size, price, in_stock, ts) VALUES (?, ?, ?, ?, ?, ?)
+	`, chatID, article, size, price, inStock, ts.UTC())
+	if err != nil {
+		return fmt.Errorf("ошибка при записи истории цены: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) PriceHistory(chatID int64, article, size string, since time.Time) ([]PricePoint, error) {
+	rows, err := s.db.Query(`
+		SELECT price, in_stock, ts FROM price_history
+		WHERE chat_id = ? AND article = ? AND size = ? AND ts >= ?
+		ORDER BY ts ASC
+	`, chatID, article, size, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении истории цены: %w", err)
+	}
+	defer rows.Close()
+
+	var points []PricePoint
+	for rows.Next() {
+		var p PricePoint
+		if err := rows.Scan(&p.Price, &p.InStock, &p.Time); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении точки истории цены: %w", err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при переборе истории цены: %w", err)
+	}
+	return points, nil
+}
+
+// --- ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ ДЛЯ /history ---
+
+// parseSince разбирает окно вида "7d", "24h", "30m" в time.Time отсчёта.
+func parseSince(window string) (time.Time, error) {
+	d, err := parseWindowDuration(window)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-d), nil
+}
+
+// parseWindowDuration разбирает окно вида "7d", "24h", "30m" в time.Duration.
+// Используется и для /history (см. parseSince), и для ALERT_LOOKBACK_WINDOW
+// (см. checker.go), поэтому оба принимают одинаковый формат периода.
+func parseWindowDuration(window string) (time.Duration, error) {
+	if len(window) < 2 {
+		return 0, fmt.Errorf("некорректный период: %s", window)
+	}
+	unit := window[len(window)-1]
+	n, err := strconv.Atoi(window[:len(window)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("некорректный период: %s", window)
+	}
+	switch unit {
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'm':
+		return time.Duration(n) * time.Minute, nil
+	default:
+		return 0, fmt.Errorf("неизвестная единица периода: %c", unit)
+	}
+}
+
+// inStockPrices отфильтровывает точки с отсутствующим на складе товаром:
+// для них Price всегда 0 (см. processCheckJob) и они не отражают
+// реальную цену, поэтому не должны влиять на min/avg/max и спарклайн.
+func inStockPrices(points []PricePoint) []PricePoint {
+	filtered := make([]PricePoint, 0, len(points))
+	for _, p := range points {
+		if p.InStock {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// sparkline рисует мини-график цен символами Unicode-блоков.
+func sparkline(points []PricePoint) string {
+	points = inStockPrices(points)
+	if len(points) == 0 {
+		return ""
+	}
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	min, max := points[0].Price, points[0].Price
+	for _, p := range points {
+		if p.Price < min {
+			min = p.Price
+		}
+		if p.Price > max {
+			max = p.Price
+		}
+	}
+	var b strings.Builder
+	for _, p := range points {
+		if max == min {
+			b.WriteRune(blocks[0])
+			continue
+		}
+		idx := int((p.Price - min) / (max - min) * float64(len(blocks)-1))
+		b.WriteRune(blocks[idx])
+	}
+	return b.String()
+}
+
+// priceSummary считает минимум, среднее и максимум по истории цены,
+// игнорируя опросы, когда товара не было в наличии (см. inStockPrices).
+func priceSummary(points []PricePoint) (min, avg, max float64) {
+	points = inStockPrices(points)
+	if len(points) == 0 {
+		return 0, 0, 0
+	}
+	min, max = points[0].Price, points[0].Price
+	var sum float64
+	for _, p := range points {
+		if p.Price < min {
+			min = p.Price
+		}
+		if p.Price > max {
+			max = p.Price
+		}
+		sum += p.Price
+	}
+	avg = sum / float64(len(points))
+	return min, avg, max
+}
@@ -0,0 +1,163 @@
+// store_test.go
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	cases := []struct {
+		window  string
+		wantErr bool
+	}{
+		{"7d", false},
+		{"24h", false},
+		{"30m", false},
+		{"0d", true},
+		{"-1d", true},
+		{"7", true},
+		{"7x", true},
+		{"", true},
+	}
+	for _, c := range cases {
+		since, err := parseSince(c.window)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseSince(%q): ожидалась ошибка, её нет", c.window)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSince(%q): неожиданная ошибка: %v", c.window, err)
+			continue
+		}
+		if !since.Before(time.Now()) {
+			t.Errorf("parseSince(%q) = %v, ожидалось время в прошлом", c.window, since)
+		}
+	}
+}
+
+func TestPriceSummaryIgnoresOutOfStock(t *testing.T) {
+	now := time.Now()
+	points := []PricePoint{
+		{Price: 100.5, InStock: true, Time: now.Add(-2 * time.Hour)},
+		{Price: 0, InStock: false, Time: now.Add(-1 * time.Hour)},
+		{Price: 95, InStock: true, Time: now},
+	}
+	min, avg, max := priceSummary(points)
+	if min != 95 {
+		t.Errorf("min = %v, ожидалось 95 (out-of-stock точка не должна считаться)", min)
+	}
+	if max != 100.5 {
+		t.Errorf("max = %v, ожидалось 100.5", max)
+	}
+	wantAvg := (100.5 + 95) / 2
+	if avg != wantAvg {
+		t.Errorf("avg = %v, ожидалось %v", avg, wantAvg)
+	}
+}
+
+func TestPriceSummaryAllOutOfStock(t *testing.T) {
+	points := []PricePoint{{Price: 0, InStock: false, Time: time.Now()}}
+	min, avg, max := priceSummary(points)
+	if min != 0 || avg != 0 || max != 0 {
+		t.Errorf("priceSummary(все не в наличии) = (%v, %v, %v), ожидалось (0, 0, 0)", min, avg, max)
+	}
+}
+
+func TestSparklineIgnoresOutOfStock(t *testing.T) {
+	points := []PricePoint{
+		{Price: 100, InStock: true},
+		{Price: 0, InStock: false},
+		{Price: 100, InStock: true},
+	}
+	// Обе оставшиеся точки равны, значит спарклайн не должен проваливаться к нулю.
+	got := sparkline(points)
+	want := sparkline([]PricePoint{{Price: 100, InStock: true}, {Price: 100, InStock: true}})
+	if got != want {
+		t.Errorf("sparkline с out-of-stock точкой = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestSparklineEmpty(t *testing.T) {
+	if got := sparkline(nil); got != "" {
+		t.Errorf("sparkline(nil) = %q, ожидалось пустую строку", got)
+	}
+}
+
+func TestSQLiteStoreTrackingRoundTrip(t *testing.T) {
+	s, err := newSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("newSQLiteStore: %v", err)
+	}
+
+	item := TrackedItem{
+		ProductName:    "Кроссовки",
+		RequestedSizes: map[string]bool{"42": true},
+		LastPrices:     map[string]float64{"42": 99.9},
+		Channels:       []string{"telegram"},
+		TargetPrice:    map[string]float64{"42": 80},
+		AlertPercent:   10,
+	}
+	if err := s.UpsertTracking(1, "wb:123", item); err != nil {
+		t.Fatalf("UpsertTracking: %v", err)
+	}
+
+	all, err := s.ListTracking()
+	if err != nil {
+		t.Fatalf("ListTracking: %v", err)
+	}
+	got, ok := all[1]["wb:123"]
+	if !ok {
+		t.Fatalf("ListTracking не вернул сохранённый товар")
+	}
+	if got.ProductName != item.ProductName || got.AlertPercent != item.AlertPercent || got.TargetPrice["42"] != 80 {
+		t.Errorf("ListTracking вернул %+v, ожидалось соответствие %+v", got, item)
+	}
+
+	if err := s.DeleteTracking(1, "wb:123"); err != nil {
+		t.Fatalf("DeleteTracking: %v", err)
+	}
+	all, err = s.ListTracking()
+	if err != nil {
+		t.Fatalf("ListTracking после удаления: %v", err)
+	}
+	if _, ok := all[1]["wb:123"]; ok {
+		t.Errorf("товар всё ещё присутствует после DeleteTracking")
+	}
+}
+
+func TestSQLiteStorePriceHistory(t *testing.T) {
+	s, err := newSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("newSQLiteStore: %v", err)
+	}
+
+	now := time.Now()
+	if err := s.RecordPricePoint(1, "wb:123", "42", 100, true, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("RecordPricePoint: %v", err)
+	}
+	if err := s.RecordPricePoint(1, "wb:123", "42", 90, true, now); err != nil {
+		t.Fatalf("RecordPricePoint: %v", err)
+	}
+
+	points, err := s.PriceHistory(1, "wb:123", "42", now.Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("PriceHistory: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("PriceHistory вернул %d точек, ожидалось 2", len(points))
+	}
+	if points[0].Price != 100 || points[1].Price != 90 {
+		t.Errorf("PriceHistory вернул цены %v, ожидался порядок по возрастанию времени", points)
+	}
+
+	recent, err := s.PriceHistory(1, "wb:123", "42", now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("PriceHistory: %v", err)
+	}
+	if len(recent) != 1 || recent[0].Price != 90 {
+		t.Errorf("PriceHistory с узким окном вернул %v, ожидалась только последняя точка", recent)
+	}
+}
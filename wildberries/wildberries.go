@@ -0,0 +1,111 @@
+// Package wildberries реализует marketplace.Marketplace поверх карточного API Wildberries.
+package wildberries
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"wb-price-bot/marketplace"
+)
+
+// --- Структуры для парсинга JSON ответа от WB API ---
+
+type priceInfo struct {
+	Product   int `json:"product"`
+	Logistics int `json:"logistics"`
+}
+
+type size struct {
+	Name   string        `json:"name"`
+	Stocks []interface{} `json:"stocks"`
+	Price  *priceInfo    `json:"price"`
+}
+
+type color struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+type product struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Sizes  []size  `json:"sizes"`
+	Colors []color `json:"colors"`
+}
+
+type productData struct {
+	Products []product `json:"products"`
+}
+
+// Client - клиент карточного API Wildberries (card.wb.ru).
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient создаёт клиент Wildberries с таймаутом на запрос к API.
+func NewClient() *Client {
+	return &Client{httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *Client) Name() string { return "wb" }
+
+func (c *Client) Fetch(article string) (*marketplace.ProductInfo, error) {
+	url := fmt.Sprintf("https://card.wb.ru/cards/v4/detail?appType=1&curr=byn&dest=-8144334&spp=30&nm=%s", article)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании запроса: %w", err)
+	}
+	req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36")
+	req.Header.Add("Referer", fmt.Sprintf("https://www.wildberries.by/catalog/%s/detail.aspx", article))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса к WB API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return nil, &marketplace.RetryableError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении тела ответа: %w", err)
+	}
+
+	var apiResponse productData
+	if err := json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&apiResponse); err != nil {
+		return nil, fmt.Errorf("ошибка при декодировании JSON: %w. Ответ сервера был: %s", err, string(bodyBytes))
+	}
+	if len(apiResponse.Products) == 0 {
+		return nil, fmt.Errorf("товар с артикулом %s не найден", article)
+	}
+	return toProductInfo(&apiResponse.Products[0]), nil
+}
+
+// toProductInfo переводит WB-специфичный ответ в общий marketplace.ProductInfo.
+func toProductInfo(p *product) *marketplace.ProductInfo {
+	name := p.Name
+	if len(p.Colors) > 0 {
+		name = name + " " + p.Colors[0].Name
+	}
+	info := &marketplace.ProductInfo{
+		ID:   fmt.Sprintf("%d", p.ID),
+		Name: name,
+	}
+	for _, s := range p.Sizes {
+		var sizePrice float64
+		var inStock bool
+		if len(s.Stocks) > 0 && s.Price != nil {
+			sizePrice = float64(s.Price.Product+s.Price.Logistics) / 100.0
+			inStock = true
+		}
+		info.Sizes = append(info.Sizes, marketplace.SizeInfo{Name: s.Name, Price: sizePrice, InStock: inStock})
+	}
+	return info
+}